@@ -37,4 +37,26 @@ type Compilation struct {
 	//[SWH|+]
 	GoGenerate bool   `json:"goGenerate"`
 	Tags       string `json:"tags"`
+
+	//ArchiveFormat controls how each compiled binary is packaged:
+	//"gz" (raw gzip), "zip", "tgz" (tar.gz) or "auto" (zip on windows,
+	//gz everywhere else). Defaults to "auto" when empty.
+	ArchiveFormat string `json:"archiveFormat"`
+	//Sign requests a GPG detached signature for every archive and for
+	//SHA256SUMS, using the GPG_KEY/GPG_PASSPHRASE env vars.
+	Sign bool `json:"sign"`
+
+	//TargetErrors records the build error (if any) for each osarch that
+	//failed, keyed by "os/arch", so a single bad target doesn't abort
+	//the rest of the parallel build
+	TargetErrors map[string]string `json:"targetErrors,omitempty"`
+
+	//Reproducible strips local build-machine state (paths, VCS metadata,
+	//wall-clock build time) from the output so rebuilding the same
+	//commit on a different machine produces byte-identical binaries
+	Reproducible bool `json:"reproducible"`
+
+	//TargetCache records "hit" or "miss" per osarch against the shared
+	//artifact cache, so the UI can show a "cached" badge
+	TargetCache map[string]string `json:"targetCache,omitempty"`
 }
@@ -54,6 +54,16 @@ type serverConfig struct {
 	NumCPU                 int
 	Platforms              Platforms
 	BinVersion             string
+	//Releasers lists the backends that authenticated successfully at
+	//startup, i.e. the values accepted as Compilation.Releaser
+	Releasers []string
+	//per-releaser configuration, surfaced so the frontend can show the
+	//user where their assets will actually end up
+	S3Bucket        string `json:",omitempty"`
+	S3Endpoint      string `json:",omitempty"`
+	GitLabProjectID string `json:",omitempty"`
+	GitLabBaseURL   string `json:",omitempty"`
+	GiteaBaseURL    string `json:",omitempty"`
 }
 
 type serverState struct {
@@ -91,31 +101,40 @@ func New() (http.Handler, error) {
 	if u, err := user.Current(); err == nil {
 		userMessage = fmt.Sprintf(" (process user: %s)", u.Username)
 	}
-	//prepare temp dir
-	if err := os.RemoveAll(tempBuild); err != nil && !os.IsNotExist(err) {
+	//prepare temp dir, preserving the persistent build cache across restarts
+	if err := clearTempBuild(); err != nil {
 		return nil, fmt.Errorf("Failed to clear temporary directory: %s", err)
 	}
 	if err := os.Mkdir(tempBuild, 0755); err != nil && !os.IsExist(err) {
 		return nil, fmt.Errorf("Failed to create temporary directory: %s", err)
 	}
+	if err := ensureCacheDirs(); err != nil {
+		return nil, fmt.Errorf("Failed to create build cache directory: %s", err)
+	}
 	//
 	s := &goxHandler{
 		q:      make(chan *Compilation, maxQueue),
 		logger: NewLogger(),
 		releasers: map[string]release.ReleaseHost{
 			"github": release.Github,
-			// "bintray": release.Bintray,
-			// "s3": TODO,
+			"s3":     release.S3,
+			"gitlab": release.GitLab,
+			"gitea":  release.Gitea,
 		},
 		files: static.FileSystemHandler(),
 		config: serverConfig{
-			Version:    strings.TrimPrefix(runtime.Version(), "go"),
-			Bin:        goBin,
-			OS:         runtime.GOOS,
-			Arch:       runtime.GOARCH,
-			NumCPU:     runtime.NumCPU(),
-			Platforms:  platforms,
-			BinVersion: binVersion,
+			Version:         strings.TrimPrefix(runtime.Version(), "go"),
+			Bin:             goBin,
+			OS:              runtime.GOOS,
+			Arch:            runtime.GOARCH,
+			NumCPU:          runtime.NumCPU(),
+			Platforms:       platforms,
+			BinVersion:      binVersion,
+			S3Bucket:        os.Getenv("S3_BUCKET"),
+			S3Endpoint:      os.Getenv("S3_ENDPOINT"),
+			GitLabProjectID: os.Getenv("GITLAB_PROJECT_ID"),
+			GitLabBaseURL:   os.Getenv("GITLAB_BASE_URL"),
+			GiteaBaseURL:    os.Getenv("GITEA_BASE_URL"),
 		},
 		state: serverState{
 			Log:       map[string]*message{},
@@ -149,6 +168,7 @@ func New() (http.Handler, error) {
 	for id, r := range s.releasers {
 		if err := r.Auth(); err == nil {
 			s.Printf("%s authenticated\n", id)
+			s.config.Releasers = append(s.config.Releasers, id)
 		} else {
 			s.Printf("%s\n", err)
 		}
@@ -190,6 +210,10 @@ func (s *goxHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 		velox.JS.ServeHTTP(w, r)
 	} else if base == "config" {
 		s.configReq(w, r)
+	} else if base == "releasers" {
+		s.releasersReq(w, r)
+	} else if r.URL.Path == "/cache/purge" {
+		s.cachePurgeReq(w, r)
 	} else if base == "compile" {
 		s.enqueueReq(w, r)
 	} else if strings.HasPrefix(r.URL.Path, "/download") {
@@ -205,10 +229,27 @@ func (s *goxHandler) configReq(w http.ResponseWriter, r *http.Request) {
 	w.Write(b)
 }
 
+//downloadable suffixes, appended in order when the requested file doesn't
+//exist as named (archives, checksums and signatures are all served as-is)
+var downloadSuffixes = []string{".gz", ".tar.gz", ".zip", ".asc"}
+
+//releasersReq lists the releaser backends that authenticated at startup
+func (s *goxHandler) releasersReq(w http.ResponseWriter, r *http.Request) {
+	b, _ := json.MarshalIndent(s.config.Releasers, "", "  ")
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(b)
+}
+
 func (s *goxHandler) downloadReq(w http.ResponseWriter, r *http.Request) {
-	file := filepath.Join(tempBuild, strings.TrimPrefix(r.URL.Path, "/download/"))
-	if !strings.HasSuffix(file, ".gz") {
-		file += ".gz"
+	reqPath := strings.TrimPrefix(r.URL.Path, "/download/")
+	file := filepath.Join(tempBuild, reqPath)
+	if _, err := os.Stat(file); err != nil {
+		for _, suffix := range downloadSuffixes {
+			if candidate := file + suffix; fileExists(candidate) {
+				file = candidate
+				break
+			}
+		}
 	}
 	f, err := os.Open(file)
 	if err != nil {
@@ -221,13 +262,27 @@ func (s *goxHandler) downloadReq(w http.ResponseWriter, r *http.Request) {
 		http.Error(w, "Stat failed: "+err.Error(), 500)
 		return
 	}
-	w.Header().Set("Content-Type", "application/octet-stream")
+	//serve archives as opaque bytes: a Content-Encoding of gzip would let
+	//compliant clients transparently decompress them on save, leaving the
+	//user with a file that no longer matches its SHA256SUMS entry
+	contentType := "application/octet-stream"
+	switch {
+	case strings.HasSuffix(file, ".tar.gz"), strings.HasSuffix(file, ".gz"):
+		contentType = "application/gzip"
+	case strings.HasSuffix(file, ".zip"):
+		contentType = "application/zip"
+	}
+	w.Header().Set("Content-Type", contentType)
 	w.Header().Set("Content-Length", fmt.Sprintf("%d", info.Size()))
-	w.Header().Set("Content-Encoding", "gzip")
-	w.Header().Set("Content-Disposition", "attachment; filename="+filepath.Base(strings.TrimSuffix(file, ".gz")))
+	w.Header().Set("Content-Disposition", "attachment; filename="+filepath.Base(file))
 	io.Copy(w, f)
 }
 
+func fileExists(path string) bool {
+	_, err := os.Stat(path)
+	return err == nil
+}
+
 func (s *goxHandler) enqueueReq(w http.ResponseWriter, r *http.Request) {
 
 	b, err := ioutil.ReadAll(r.Body)
@@ -245,9 +300,6 @@ func (s *goxHandler) enqueueReq(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	//disabled
-	c.Releaser = ""
-
 	err = s.enqueue(c)
 	if err != nil {
 		w.WriteHeader(400)
@@ -259,6 +311,22 @@ func (s *goxHandler) enqueue(c *Compilation) error {
 	if c.Package == "" {
 		return errors.New("Missing package")
 	}
+	if c.Releaser != "" {
+		//s.releasers lists every backend the server knows how to speak
+		//to, regardless of whether it authenticated; s.config.Releasers
+		//is the subset that actually passed Auth() at startup, which is
+		//what /releasers advertises and what enqueue must honor
+		authenticated := false
+		for _, id := range s.config.Releasers {
+			if id == c.Releaser {
+				authenticated = true
+				break
+			}
+		}
+		if !authenticated {
+			return fmt.Errorf("Unknown releaser %q", c.Releaser)
+		}
+	}
 	if c.Version == "" {
 		return errors.New("Missing version")
 	}
@@ -0,0 +1,123 @@
+package handler
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"os/exec"
+)
+
+//archiveFormat resolves the "auto" alias against the target OS
+func archiveFormat(format, osname string) string {
+	switch format {
+	case "zip", "tgz", "gz":
+		return format
+	default: //"" or "auto"
+		if osname == "windows" {
+			return "zip"
+		}
+		return "gz"
+	}
+}
+
+//archiveFile packages b (the compiled binary named entryName) using the
+//given format, returning the archive bytes and the filename suffix it
+//should be given (e.g. ".zip", ".tar.gz", ".gz")
+func archiveFile(format, entryName string, b []byte) ([]byte, string, error) {
+	switch format {
+	case "zip":
+		buf := bytes.Buffer{}
+		zw := zip.NewWriter(&buf)
+		fw, err := zw.Create(entryName)
+		if err != nil {
+			return nil, "", err
+		}
+		if _, err := fw.Write(b); err != nil {
+			return nil, "", err
+		}
+		if err := zw.Close(); err != nil {
+			return nil, "", err
+		}
+		return buf.Bytes(), ".zip", nil
+	case "tgz":
+		buf := bytes.Buffer{}
+		gz := gzip.NewWriter(&buf)
+		tw := tar.NewWriter(gz)
+		hdr := &tar.Header{
+			Name: entryName,
+			Mode: 0755,
+			Size: int64(len(b)),
+		}
+		if err := tw.WriteHeader(hdr); err != nil {
+			return nil, "", err
+		}
+		if _, err := tw.Write(b); err != nil {
+			return nil, "", err
+		}
+		if err := tw.Close(); err != nil {
+			return nil, "", err
+		}
+		if err := gz.Close(); err != nil {
+			return nil, "", err
+		}
+		return buf.Bytes(), ".tar.gz", nil
+	default: //"gz"
+		buf := bytes.Buffer{}
+		gz := gzip.NewWriter(&buf)
+		if _, err := gz.Write(b); err != nil {
+			return nil, "", err
+		}
+		if err := gz.Close(); err != nil {
+			return nil, "", err
+		}
+		return buf.Bytes(), ".gz", nil
+	}
+}
+
+//sha256Sum returns a "SHA256SUMS"-formatted line for the given filename/bytes
+func sha256Sum(filename string, b []byte) string {
+	sum := sha256.Sum256(b)
+	return fmt.Sprintf("%s  %s\n", hex.EncodeToString(sum[:]), filename)
+}
+
+//gpgSign produces a detached ASCII-armored signature for b using the key
+//material configured via the GPG_KEY/GPG_PASSPHRASE env vars. The key is
+//imported into a scratch keyring for the lifetime of the call.
+func gpgSign(b []byte) ([]byte, error) {
+	gpgBin, err := exec.LookPath("gpg")
+	if err != nil {
+		return nil, fmt.Errorf("gpg is not installed")
+	}
+	key := os.Getenv("GPG_KEY")
+	if key == "" {
+		return nil, fmt.Errorf("GPG_KEY is not set")
+	}
+	home, err := os.MkdirTemp("", "cloudgox-gnupg")
+	if err != nil {
+		return nil, err
+	}
+	defer os.RemoveAll(home)
+
+	importCmd := exec.Command(gpgBin, "--batch", "--homedir", home, "--import")
+	importCmd.Stdin = bytes.NewBufferString(key)
+	if out, err := importCmd.CombinedOutput(); err != nil {
+		return nil, fmt.Errorf("gpg import failed: %s: %s", err, out)
+	}
+
+	args := []string{"--batch", "--homedir", home, "--armor", "--detach-sign"}
+	if pass := os.Getenv("GPG_PASSPHRASE"); pass != "" {
+		args = append([]string{"--pinentry-mode", "loopback", "--passphrase", pass}, args...)
+	}
+	signCmd := exec.Command(gpgBin, args...)
+	signCmd.Stdin = bytes.NewBuffer(b)
+	out, err := signCmd.Output()
+	if err != nil {
+		return nil, fmt.Errorf("gpg sign failed: %s", err)
+	}
+	return out, nil
+}
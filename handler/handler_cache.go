@@ -0,0 +1,80 @@
+package handler
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"os"
+	"path/filepath"
+)
+
+//cacheRoot holds everything that should survive across compilation jobs:
+//the shared Go build/module caches and the keyed artifact cache
+var cacheRoot = filepath.Join(tempBuild, "cache")
+var gocacheRoot = filepath.Join(cacheRoot, "gocache")
+var gomodcacheRoot = filepath.Join(cacheRoot, "gomodcache")
+var artifactCacheRoot = filepath.Join(cacheRoot, "artifacts")
+
+//clearTempBuild wipes every job directory under tempBuild left over from
+//a previous run, without touching the persistent cache/ subdirectory
+func clearTempBuild() error {
+	entries, err := os.ReadDir(tempBuild)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return err
+	}
+	for _, entry := range entries {
+		if entry.Name() == "cache" {
+			continue
+		}
+		if err := os.RemoveAll(filepath.Join(tempBuild, entry.Name())); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+//ensureCacheDirs creates the cache directory tree, leaving any existing
+//cached content (from a previous process) in place
+func ensureCacheDirs() error {
+	for _, dir := range []string{gocacheRoot, gomodcacheRoot, artifactCacheRoot} {
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+//artifactCacheKey identifies a compiled binary by everything that can
+//change its bytes: the package, the build target within it, the commit
+//it was built from, the target osarch, build tags, the resolved
+//ldflags, the build environment (cgo on/off plus any custom env vars)
+//and the Go toolchain version
+func artifactCacheKey(pkg, target, commitish, osarch, tags, ldflags, env, goVersion string) string {
+	sum := sha256.Sum256([]byte(pkg + "\x00" + target + "\x00" + commitish + "\x00" + osarch + "\x00" + tags + "\x00" + ldflags + "\x00" + env + "\x00" + goVersion))
+	return hex.EncodeToString(sum[:])
+}
+
+func artifactCachePath(key string) string {
+	return filepath.Join(artifactCacheRoot, key)
+}
+
+//cachePurgeReq wipes the shared gocache/gomodcache/artifact caches
+func (s *goxHandler) cachePurgeReq(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if err := os.RemoveAll(cacheRoot); err != nil {
+		http.Error(w, "failed to purge cache: "+err.Error(), 500)
+		return
+	}
+	if err := ensureCacheDirs(); err != nil {
+		http.Error(w, "failed to recreate cache: "+err.Error(), 500)
+		return
+	}
+	s.Printf("cache purged\n")
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -1,8 +1,6 @@
 package handler
 
 import (
-	"bytes"
-	"compress/gzip"
 	"errors"
 	"fmt"
 	"io/ioutil"
@@ -10,6 +8,8 @@ import (
 	"os/exec"
 	"path"
 	"path/filepath"
+	"runtime"
+	"sort"
 	"strconv"
 	"strings"
 	"sync"
@@ -23,6 +23,47 @@ import (
 //temporary storeage for the resulting binaries
 var tempBuild = path.Join(os.TempDir(), "cloudgox")
 
+//sourceDateEpoch returns the checked-out commit's author timestamp (as
+//used by SOURCE_DATE_EPOCH), falling back to the current time if it
+//can't be determined
+func sourceDateEpoch(pkgDir string) string {
+	cmd := exec.Command("git", "log", "-1", "--pretty=%ct")
+	cmd.Dir = pkgDir
+	if out, err := cmd.Output(); err == nil {
+		if epoch := strings.TrimSpace(string(out)); epoch != "" {
+			return epoch
+		}
+	}
+	return strconv.FormatInt(time.Now().Unix(), 10)
+}
+
+//buildEnvFingerprint captures the parts of the build environment that
+//affect the compiled binary's bytes but aren't already part of the
+//ldflags/tags/osarch, so the artifact cache doesn't conflate e.g. a
+//CGO_ENABLED=0 static build with a cgo-linked one
+func buildEnvFingerprint(cgo bool, env map[string]string) string {
+	keys := make([]string, 0, len(env))
+	for k := range env {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	parts := make([]string, 0, len(keys)+1)
+	parts = append(parts, "cgo="+strconv.FormatBool(cgo))
+	for _, k := range keys {
+		parts = append(parts, k+"="+env[k])
+	}
+	return strings.Join(parts, ",")
+}
+
+//maxParallelBuilds returns how many osarch targets may be compiled at
+//once, sized by runtime.NumCPU() unless overridden via MAX_PARALLEL_BUILDS
+func maxParallelBuilds() int {
+	if n, err := strconv.Atoi(os.Getenv("MAX_PARALLEL_BUILDS")); err == nil && n > 0 {
+		return n
+	}
+	return runtime.NumCPU()
+}
+
 //server's compile method
 func (s *goxHandler) compile(c *Compilation) error {
 	s.Printf("compiling %s...\n", c.Package)
@@ -56,6 +97,9 @@ func (s *goxHandler) compile(c *Compilation) error {
 		return fmt.Errorf("Failed to create build directory %s", err)
 	}
 	goEnv["GOPATH"] = goPath
+	//share the module cache across every build instead of re-downloading
+	//dependencies per-job
+	goEnv["GOMODCACHE"] = gomodcacheRoot
 	s.Printf("GOPATH: %s", goPath)
 	//set this builds' package directory
 	pkgDir := filepath.Join(goPath, "src", c.Package)
@@ -102,23 +146,60 @@ func (s *goxHandler) compile(c *Compilation) error {
 		if out, err := cmd.Output(); err == nil {
 			currCommitish := strings.TrimSuffix(string(out), "\n")
 			c.Variables[c.CommitVar] = currCommitish
+			//record the resolved commit back onto the job so the artifact
+			//cache key reflects what was actually built, not an empty,
+			//unpinned commitish
+			c.Commitish = currCommitish
 		}
 	}
 	if len(c.Label) > 0 {
 		c.Variables[c.LabelVar] = c.Label
 	}
-	//calculate ldflags
+	//calculate ldflags. cacheLdflags mirrors ldflags but omits wall-clock
+	//derived values (main.BUILD_TIME, unless c.Reproducible pins it to
+	//the commit's SOURCE_DATE_EPOCH) so the artifact cache key doesn't
+	//change on every single build of an otherwise-identical commit
 	ldflags := []string{}
+	cacheLdflags := []string{}
 	if c.Shrink {
 		s.Printf("ld-flag: -s -w (shrink)")
 		ldflags = append(ldflags, "-s", "-w")
+		cacheLdflags = append(cacheLdflags, "-s", "-w")
 	}
 	c.Variables["main.CLOUD_GOX"] = "1"
-	c.Variables["main.BUILD_TIME"] = strconv.FormatInt(time.Now().Unix(), 10)
-	for k, v := range c.Variables {
+	if c.Reproducible {
+		//derive SOURCE_DATE_EPOCH from the checked-out commit instead of
+		//the wall clock, so rebuilding the same commit elsewhere is
+		//byte-identical; export it to the build environment too, since
+		//that's the variable toolchains/build steps actually honor
+		epoch := sourceDateEpoch(pkgDir)
+		s.Printf("SOURCE_DATE_EPOCH: %s\n", epoch)
+		c.Variables["main.BUILD_TIME"] = epoch
+		goEnv["SOURCE_DATE_EPOCH"] = epoch
+	} else {
+		c.Variables["main.BUILD_TIME"] = strconv.FormatInt(time.Now().Unix(), 10)
+	}
+	//emit -X flags in sorted key order so the ldflag string (and thus
+	//the resulting binary) is deterministic across runs
+	varNames := make([]string, 0, len(c.Variables))
+	for k := range c.Variables {
+		varNames = append(varNames, k)
+	}
+	sort.Strings(varNames)
+	for _, k := range varNames {
+		v := c.Variables[k]
 		s.Printf("ld-flag-X: %s=%s", k, v)
-		ldflags = append(ldflags, "-X "+k+"="+v)
+		flag := "-X " + k + "=" + v
+		ldflags = append(ldflags, flag)
+		if k != "main.BUILD_TIME" || c.Reproducible {
+			cacheLdflags = append(cacheLdflags, flag)
+		}
 	}
+	s.Printf("ldflags: %s\n", strings.Join(ldflags, " "))
+	//sha256sums accumulates one line per archived artifact, written to
+	//SHA256SUMS once all targets/osarches have been compiled
+	sha256sums := strings.Builder{}
+
 	//compile all combinations of each target and each osarch
 	for _, t := range c.Targets {
 		target := filepath.Join(c.Package, t)
@@ -137,83 +218,196 @@ func (s *goxHandler) compile(c *Compilation) error {
 				continue
 			}
 		}
-		//compile target for all os/arch combos
-		for _, osarchstr := range c.OSArch {
-			osarch := strings.SplitN(osarchstr, "/", 2)
-			osname := osarch[0]
-			arch := osarch[1]
-			targetFilename := fmt.Sprintf("%s_%s_%s", targetName, osname, arch)
-			if osname == "windows" {
-				targetFilename += ".exe"
-			}
-			targetOut := filepath.Join(buildDir, targetFilename)
-			if _, err := os.Stat(targetDir); err != nil {
-				s.Printf("failed to find target %s\n", target)
-				continue
-			}
-			args := []string{
-				"build",
-				"-a",
-				"-v",
-				"-ldflags", strings.Join(ldflags, " "),
-				"-o", targetOut,
-			}
-			if len(c.Tags) > 0 {
-				args = append(args, "-tags", c.Tags)
-			}
-			args = append(args, ".")
-			c.Env["GOOS"] = osname
-			c.Env["GOARCH"] = arch
-			if !c.CGO {
-				s.Printf("cgo disabled")
-				c.Env["CGO_ENABLED"] = "0"
-			}
-			for k, v := range c.Env {
-				s.Printf("env: %s=%s", k, v)
-				goEnv[k] = v
-			}
-			//run go build with cross compile configuration
-			if err := s.exec(targetDir, "go", goEnv, args...); err != nil {
-				s.Printf("failed to build %s\n", targetFilename)
-				continue
-			}
-			//gzip file
-			b, err := ioutil.ReadFile(targetOut)
-			if err != nil {
-				return err
+		//compile target for all os/arch combos, fanned out across a
+		//bounded worker pool so independent osarch builds run concurrently
+		osarchSem := make(chan struct{}, maxParallelBuilds())
+		var wg sync.WaitGroup
+		var mu sync.Mutex //guards c.Files, c.TargetErrors and sha256sums
+
+		recordError := func(osarchstr string, err error) {
+			s.Printf("[%s] %s\n", osarchstr, err)
+			mu.Lock()
+			if c.TargetErrors == nil {
+				c.TargetErrors = map[string]string{}
 			}
-			gzb := bytes.Buffer{}
-			gz := gzip.NewWriter(&gzb)
-			gz.Write(b)
-			gz.Close()
-			b = gzb.Bytes()
-			targetFilename += ".gz"
-
-			//optional releaser
+			c.TargetErrors[osarchstr] = err.Error()
+			mu.Unlock()
+		}
+
+		for _, osarchstr := range c.OSArch {
+			osarchstr := osarchstr
+			osarchSem <- struct{}{}
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				defer func() { <-osarchSem }()
+
+				osarch := strings.SplitN(osarchstr, "/", 2)
+				osname := osarch[0]
+				arch := osarch[1]
+				targetFilename := fmt.Sprintf("%s_%s_%s", targetName, osname, arch)
+				if osname == "windows" {
+					targetFilename += ".exe"
+				}
+				targetOut := filepath.Join(buildDir, targetFilename)
+				if _, err := os.Stat(targetDir); err != nil {
+					recordError(osarchstr, fmt.Errorf("failed to find target %s", target))
+					return
+				}
+				ldflagStr := strings.Join(ldflags, " ")
+				cacheLdflagStr := strings.Join(cacheLdflags, " ")
+				envFingerprint := buildEnvFingerprint(c.CGO, c.Env)
+				cacheKey := artifactCacheKey(c.Package, target, c.Commitish, osarchstr, c.Tags, cacheLdflagStr, envFingerprint, s.config.BinVersion)
+				recordCache := func(status string) {
+					mu.Lock()
+					if c.TargetCache == nil {
+						c.TargetCache = map[string]string{}
+					}
+					c.TargetCache[osarchstr] = status
+					mu.Unlock()
+				}
+				if cached, err := ioutil.ReadFile(artifactCachePath(cacheKey)); err == nil {
+					s.Printf("[%s] cache hit (%s)\n", osarchstr, cacheKey)
+					if err := ioutil.WriteFile(targetOut, cached, 0755); err != nil {
+						recordError(osarchstr, fmt.Errorf("failed to re-link cached artifact: %s", err))
+						return
+					}
+					recordCache("hit")
+				} else {
+					args := []string{
+						"build",
+						"-v",
+						"-ldflags", ldflagStr,
+						"-o", targetOut,
+					}
+					if len(c.Tags) > 0 {
+						args = append(args, "-tags", c.Tags)
+					}
+					if c.Reproducible {
+						args = append(args, "-trimpath", "-buildvcs=false")
+					}
+					args = append(args, ".")
+					//each worker gets its own env, but shares the GOCACHE
+					//subdirectory for this osarch across every job so the
+					//standard library (and unchanged packages) are never
+					//recompiled from scratch
+					buildEnv := environ{}
+					for k, v := range goEnv {
+						buildEnv[k] = v
+					}
+					for k, v := range c.Env {
+						buildEnv[k] = v
+					}
+					buildEnv["GOOS"] = osname
+					buildEnv["GOARCH"] = arch
+					if !c.CGO {
+						buildEnv["CGO_ENABLED"] = "0"
+					}
+					buildEnv["GOCACHE"] = filepath.Join(gocacheRoot, osname+"_"+arch)
+					s.Printf("[%s] building (GOCACHE=%s)\n", osarchstr, buildEnv["GOCACHE"])
+					//run go build with cross compile configuration
+					if err := s.exec(targetDir, "go", buildEnv, args...); err != nil {
+						recordError(osarchstr, fmt.Errorf("failed to build %s: %s", targetFilename, err))
+						return
+					}
+					recordCache("miss")
+					if built, err := ioutil.ReadFile(targetOut); err == nil {
+						if err := ioutil.WriteFile(artifactCachePath(cacheKey), built, 0644); err != nil {
+							s.Printf("[%s] failed to populate artifact cache: %s\n", osarchstr, err)
+						}
+					}
+				}
+				//archive file (zip/tgz/gz, per c.ArchiveFormat)
+				b, err := ioutil.ReadFile(targetOut)
+				if err != nil {
+					recordError(osarchstr, err)
+					return
+				}
+				format := archiveFormat(c.ArchiveFormat, osname)
+				archived, suffix, err := archiveFile(format, targetFilename, b)
+				if err != nil {
+					recordError(osarchstr, fmt.Errorf("failed to archive %s: %s", targetFilename, err))
+					return
+				}
+				b = archived
+				mu.Lock()
+				sha256sums.WriteString(sha256Sum(targetFilename+suffix, b))
+				mu.Unlock()
+				targetFilename += suffix
+
+				//optional releaser
+				if releaser != nil {
+					once.Do(setupRelease)
+				}
+				if rel != nil {
+					if err := rel.Upload(targetFilename, b); err == nil {
+						s.Printf("%s included asset in release %s\n", c.Releaser, targetFilename)
+					} else {
+						s.Printf("%s failed to release asset %s: %s\n", c.Releaser, targetFilename, err)
+					}
+				}
+				//swap non-archived with archived
+				if err := os.Remove(targetOut); err != nil {
+					recordError(osarchstr, fmt.Errorf("asset local remove failed %s", err))
+					return
+				}
+				targetOut = filepath.Join(buildDir, targetFilename)
+				if err := ioutil.WriteFile(targetOut, b, 0755); err != nil {
+					recordError(osarchstr, fmt.Errorf("asset local write failed %s", err))
+					return
+				}
+				//optional GPG detached signature
+				if c.Sign {
+					if sig, err := gpgSign(b); err == nil {
+						sigFilename := targetFilename + ".asc"
+						if err := ioutil.WriteFile(filepath.Join(buildDir, sigFilename), sig, 0644); err == nil {
+							mu.Lock()
+							c.Files = append(c.Files, sigFilename)
+							mu.Unlock()
+							if rel != nil {
+								rel.Upload(sigFilename, sig)
+							}
+						}
+					} else {
+						s.Printf("[%s] gpg sign failed: %s\n", osarchstr, err)
+					}
+				}
+				//ready for download!
+				s.Printf("[%s] compiled %s\n", osarchstr, targetFilename)
+				mu.Lock()
+				c.Files = append(c.Files, targetFilename)
+				mu.Unlock()
+				s.state.Push()
+			}()
+		}
+		wg.Wait()
+	}
+
+	//emit SHA256SUMS (and an optional detached signature) alongside the archives
+	if sha256sums.Len() > 0 {
+		sumsFile := filepath.Join(buildDir, "SHA256SUMS")
+		sumsBytes := []byte(sha256sums.String())
+		if err := ioutil.WriteFile(sumsFile, sumsBytes, 0644); err != nil {
+			s.Printf("failed to write SHA256SUMS: %s\n", err)
+		} else {
+			c.Files = append(c.Files, "SHA256SUMS")
 			if releaser != nil {
 				once.Do(setupRelease)
 			}
 			if rel != nil {
-				if err := rel.Upload(targetFilename, b); err == nil {
-					s.Printf("%s included asset in release %s\n", c.Releaser, targetFilename)
+				rel.Upload("SHA256SUMS", sumsBytes)
+			}
+			if c.Sign {
+				if sig, err := gpgSign(sumsBytes); err == nil {
+					ioutil.WriteFile(sumsFile+".asc", sig, 0644)
+					c.Files = append(c.Files, "SHA256SUMS.asc")
+					if rel != nil {
+						rel.Upload("SHA256SUMS.asc", sig)
+					}
 				} else {
-					s.Printf("%s failed to release asset %s: %s\n", c.Releaser, targetFilename, err)
+					s.Printf("gpg sign failed for SHA256SUMS: %s\n", err)
 				}
 			}
-			//swap non-gzipd with gzipd
-			if err := os.Remove(targetOut); err != nil {
-				s.Printf("asset local remove failed %s\n", err)
-				continue
-			}
-			targetOut += ".gz"
-			if err := ioutil.WriteFile(targetOut, b, 0755); err != nil {
-				s.Printf("asset local write failed %s\n", err)
-				continue
-			}
-			//ready for download!
-			s.Printf("compiled %s\n", targetFilename)
-			c.Files = append(c.Files, targetFilename)
-			s.state.Push()
 		}
 	}
 
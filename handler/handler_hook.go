@@ -0,0 +1,376 @@
+package handler
+
+import (
+	"crypto/hmac"
+	"crypto/sha1"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"hash"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"strings"
+
+	"gopkg.in/yaml.v2"
+)
+
+//cloudGoxConfigFile is the per-repo config fetched at the pushed ref
+//that tells the webhook pipeline what to build
+const cloudGoxConfigFile = ".cloud-gox.yml"
+
+//repoConfig is the schema of .cloud-gox.yml
+type repoConfig struct {
+	Platforms Platforms         `yaml:"platforms"`
+	Targets   []string          `yaml:"targets"`
+	Tags      string            `yaml:"tags"`
+	Releaser  string            `yaml:"releaser"`
+	Variables map[string]string `yaml:"variables"`
+	//Branches restricts which pushed branches trigger a build; tag and
+	//release events always trigger regardless of this list
+	Branches []string `yaml:"branches"`
+}
+
+//hookEvent is the provider-agnostic shape hookReq extracts from a
+//webhook payload before looking up the repo's .cloud-gox.yml
+type hookEvent struct {
+	provider string //"github", "gitea" or "gitlab"
+	repo     string //"owner/repo"
+	ref      string //e.g. "refs/heads/master" or "refs/tags/v1.0.0"
+	commit   string
+	isTag    bool
+}
+
+//branch returns the short branch name for a push to refs/heads/*
+func (e hookEvent) branch() string {
+	return strings.TrimPrefix(e.ref, "refs/heads/")
+}
+
+//tag returns the short tag name for a push to refs/tags/*
+func (e hookEvent) tag() string {
+	return strings.TrimPrefix(e.ref, "refs/tags/")
+}
+
+//hookReq accepts GitHub/Gitea/GitLab push, release and create webhooks,
+//verifies the signature against WEBHOOK_SECRET, and enqueues a
+//Compilation built from the pushed repo's .cloud-gox.yml
+func (s *goxHandler) hookReq(w http.ResponseWriter, r *http.Request) {
+	body, err := ioutil.ReadAll(r.Body)
+	if err != nil {
+		http.Error(w, "missing body", 400)
+		return
+	}
+
+	secret := os.Getenv("WEBHOOK_SECRET")
+	if secret == "" {
+		http.Error(w, "webhooks are not configured (WEBHOOK_SECRET unset)", 501)
+		return
+	}
+
+	eventType, provider := hookEventType(r)
+	if provider == "" {
+		http.Error(w, "unrecognised webhook provider", 400)
+		return
+	}
+	if err := verifyHookSignature(r, body, secret, provider); err != nil {
+		s.Printf("hook: rejected (%s)\n", err)
+		http.Error(w, "signature verification failed: "+err.Error(), 401)
+		return
+	}
+
+	if provider == "gitlab" {
+		//GitLab's X-Gitlab-Event header is a human label ("Push Hook",
+		//"Tag Push Hook", ...); the payload's object_kind is the
+		//machine-readable equivalent parseHookEvent expects
+		var kind struct {
+			ObjectKind string `json:"object_kind"`
+		}
+		if err := json.Unmarshal(body, &kind); err == nil {
+			eventType = kind.ObjectKind
+		}
+	}
+
+	event, ok, err := parseHookEvent(provider, eventType, body)
+	if err != nil {
+		http.Error(w, "invalid payload: "+err.Error(), 400)
+		return
+	}
+	if !ok {
+		//event we don't act on (e.g. a PR webhook); acknowledge and skip
+		w.WriteHeader(204)
+		return
+	}
+
+	s.Printf("hook: %s push to %s@%s\n", event.provider, event.repo, event.ref)
+
+	cfgBytes, err := fetchRepoFile(event, cloudGoxConfigFile)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("failed to fetch %s: %s", cloudGoxConfigFile, err), 502)
+		return
+	}
+	cfg := repoConfig{}
+	if err := yaml.Unmarshal(cfgBytes, &cfg); err != nil {
+		http.Error(w, fmt.Sprintf("invalid %s: %s", cloudGoxConfigFile, err), 400)
+		return
+	}
+
+	if !event.isTag && !branchAllowed(event.branch(), cfg.Branches) {
+		s.Printf("hook: ignoring push to %s (not in branches: list)\n", event.branch())
+		w.WriteHeader(204)
+		return
+	}
+
+	c := &Compilation{
+		Package:   packagePath(event),
+		Commitish: event.commit,
+		Targets:   cfg.Targets,
+		Tags:      cfg.Tags,
+		Releaser:  cfg.Releaser,
+		Variables: cfg.Variables,
+		Platforms: cfg.Platforms,
+		//webhook builds target arbitrary pushed repos that won't already
+		//be checked out on the build host, so they must be go-get'd
+		GoGet: true,
+	}
+	if event.isTag {
+		c.Version = event.tag()
+	} else {
+		c.Version = event.commit
+	}
+
+	if err := s.enqueue(c); err != nil {
+		http.Error(w, "failed to enqueue build: "+err.Error(), 400)
+		return
+	}
+	w.WriteHeader(202)
+}
+
+//hookEventType reads the provider-specific event-name header and
+//returns (eventType, provider); provider is "" when unrecognised.
+//X-Gitea-Event is checked first because Gitea also sends X-GitHub-Event.
+func hookEventType(r *http.Request) (eventType, provider string) {
+	if e := r.Header.Get("X-Gitea-Event"); e != "" {
+		return e, "gitea"
+	}
+	if e := r.Header.Get("X-GitHub-Event"); e != "" {
+		return e, "github"
+	}
+	if e := r.Header.Get("X-Gitlab-Event"); e != "" {
+		return e, "gitlab"
+	}
+	return "", ""
+}
+
+//verifyHookSignature checks the request's HMAC (GitHub/Gitea) or
+//shared-secret token (GitLab, which doesn't HMAC-sign payloads)
+func verifyHookSignature(r *http.Request, body []byte, secret, provider string) error {
+	if provider == "gitlab" {
+		if token := r.Header.Get("X-Gitlab-Token"); hmac.Equal([]byte(token), []byte(secret)) {
+			return nil
+		}
+		return fmt.Errorf("X-Gitlab-Token mismatch")
+	}
+	//GitHub and Gitea both sign with HMAC-SHA256 (X-Hub-Signature-256),
+	//falling back to the older HMAC-SHA1 (X-Hub-Signature) for GitHub
+	if sig := r.Header.Get("X-Hub-Signature-256"); sig != "" {
+		return verifyHMAC(sha256.New, "sha256=", sig, body, secret)
+	}
+	if sig := r.Header.Get("X-Hub-Signature"); sig != "" {
+		return verifyHMAC(sha1.New, "sha1=", sig, body, secret)
+	}
+	return fmt.Errorf("no signature header present")
+}
+
+func verifyHMAC(newHash func() hash.Hash, prefix, sig string, body []byte, secret string) error {
+	sig = strings.TrimPrefix(sig, prefix)
+	mac := hmac.New(newHash, []byte(secret))
+	mac.Write(body)
+	expected := hex.EncodeToString(mac.Sum(nil))
+	if !hmac.Equal([]byte(expected), []byte(sig)) {
+		return fmt.Errorf("signature mismatch")
+	}
+	return nil
+}
+
+//parseHookEvent extracts a hookEvent from a push/tag/release payload.
+//ok is false for event types we deliberately don't build from.
+func parseHookEvent(provider, eventType string, body []byte) (event hookEvent, ok bool, err error) {
+	switch eventType {
+	case "push":
+		var p struct {
+			Ref        string `json:"ref"`
+			After      string `json:"after"`
+			Repository struct {
+				FullName string `json:"full_name"`
+			} `json:"repository"`
+			Project struct {
+				PathWithNamespace string `json:"path_with_namespace"`
+			} `json:"project"`
+		}
+		if err = json.Unmarshal(body, &p); err != nil {
+			return
+		}
+		repo := p.Repository.FullName
+		if repo == "" {
+			repo = p.Project.PathWithNamespace
+		}
+		event = hookEvent{provider: provider, repo: repo, ref: p.Ref, commit: p.After}
+		event.isTag = strings.HasPrefix(p.Ref, "refs/tags/")
+		ok = true
+	case "create": //Gitea/GitHub "create" fires for new branches AND tags
+		var p struct {
+			Ref        string `json:"ref"`
+			RefType    string `json:"ref_type"`
+			SHA        string `json:"sha"`
+			Repository struct {
+				FullName string `json:"full_name"`
+			} `json:"repository"`
+		}
+		if err = json.Unmarshal(body, &p); err != nil {
+			return
+		}
+		if p.RefType != "tag" {
+			return event, false, nil
+		}
+		if p.SHA == "" {
+			//GitHub's "create" payload has no sha field at all (only
+			//Gitea's does) - building off whatever commit happens to be
+			//checked out would be wrong, so skip it here and rely on the
+			//"push" (and "release") events, which always carry a commit
+			return event, false, nil
+		}
+		event = hookEvent{provider: provider, repo: p.Repository.FullName, ref: "refs/tags/" + p.Ref, commit: p.SHA, isTag: true}
+		ok = true
+	case "release", "tag_push": //GitHub/Gitea "release" / GitLab "tag_push"
+		var p struct {
+			Action  string `json:"action"`
+			Release struct {
+				TagName string `json:"tag_name"`
+			} `json:"release"`
+			Ref        string `json:"ref"` //GitLab tag_push
+			After      string `json:"after"`
+			Repository struct {
+				FullName string `json:"full_name"`
+			} `json:"repository"`
+			Project struct {
+				PathWithNamespace string `json:"path_with_namespace"`
+			} `json:"project"`
+		}
+		if err = json.Unmarshal(body, &p); err != nil {
+			return
+		}
+		//GitHub/Gitea "release" webhooks fire for every action on a
+		//release (edited, deleted, prereleased, ...); only build when the
+		//release was actually (newly) published. GitLab's "tag_push"
+		//carries no action field and always means a tag was pushed.
+		if eventType == "release" && !releasePublished(p.Action) {
+			return event, false, nil
+		}
+		repo := p.Repository.FullName
+		if repo == "" {
+			repo = p.Project.PathWithNamespace
+		}
+		tagName := p.Release.TagName
+		ref := p.Ref
+		if tagName != "" && ref == "" {
+			ref = "refs/tags/" + tagName
+		}
+		//GitHub/Gitea release payloads carry no "after" commit, so check
+		//out the released tag itself rather than whatever is at HEAD
+		commit := p.After
+		if commit == "" {
+			commit = strings.TrimPrefix(ref, "refs/tags/")
+		}
+		event = hookEvent{provider: provider, repo: repo, ref: ref, commit: commit, isTag: true}
+		ok = true
+	default:
+		ok = false
+	}
+	return
+}
+
+//releasePublished reports whether a GitHub/Gitea release webhook's action
+//means the release just became publicly available, as opposed to being
+//edited, deleted, unpublished or merely prereleased
+func releasePublished(action string) bool {
+	switch action {
+	case "published", "released":
+		return true
+	default:
+		return false
+	}
+}
+
+//packagePath turns a webhook repo ("owner/repo") into the Go import
+//path cloud-gox should clone, using each self-hosted provider's
+//configured host
+func packagePath(event hookEvent) string {
+	switch event.provider {
+	case "gitea":
+		return hookHost(os.Getenv("GITEA_BASE_URL"), "gitea") + "/" + event.repo
+	case "gitlab":
+		return hookHost(os.Getenv("GITLAB_BASE_URL"), "gitlab.com") + "/" + event.repo
+	default:
+		return "github.com/" + event.repo
+	}
+}
+
+func hookHost(baseURL, fallback string) string {
+	host := strings.TrimPrefix(strings.TrimPrefix(baseURL, "https://"), "http://")
+	host = strings.TrimSuffix(host, "/")
+	if host == "" {
+		return fallback
+	}
+	return host
+}
+
+//branchAllowed reports whether branch is in allowed, or allowed is empty
+//(meaning every branch triggers a build)
+func branchAllowed(branch string, allowed []string) bool {
+	if len(allowed) == 0 {
+		return true
+	}
+	for _, b := range allowed {
+		if b == branch {
+			return true
+		}
+	}
+	return false
+}
+
+//fetchRepoFile downloads path as it exists at event.ref from the
+//provider's raw-content endpoint
+func fetchRepoFile(event hookEvent, path string) ([]byte, error) {
+	var ref string
+	if event.isTag {
+		ref = event.tag()
+	} else {
+		ref = event.branch()
+	}
+	var url string
+	switch event.provider {
+	case "github":
+		url = fmt.Sprintf("https://raw.githubusercontent.com/%s/%s/%s", event.repo, ref, path)
+	case "gitea":
+		baseURL := strings.TrimSuffix(os.Getenv("GITEA_BASE_URL"), "/")
+		url = fmt.Sprintf("%s/%s/raw/%s/%s", baseURL, event.repo, ref, path)
+	case "gitlab":
+		baseURL := strings.TrimSuffix(os.Getenv("GITLAB_BASE_URL"), "/")
+		if baseURL == "" {
+			baseURL = "https://gitlab.com"
+		}
+		url = fmt.Sprintf("%s/%s/-/raw/%s/%s", baseURL, event.repo, ref, path)
+	default:
+		return nil, fmt.Errorf("unsupported provider %q", event.provider)
+	}
+	resp, err := http.Get(url)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("%s (%s)", url, resp.Status)
+	}
+	return ioutil.ReadAll(resp.Body)
+}
@@ -0,0 +1,109 @@
+package release
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+)
+
+//Gitea releases assets via the Gitea release API, which mirrors
+//GitHub's shape closely enough to reuse the same upload flow. Configured
+//with GITEA_TOKEN and GITEA_BASE_URL (the self-hosted instance's URL).
+var Gitea ReleaseHost = &giteaHost{}
+
+type giteaHost struct {
+	token, baseURL string
+}
+
+func (h *giteaHost) Auth() error {
+	h.token = os.Getenv("GITEA_TOKEN")
+	h.baseURL = os.Getenv("GITEA_BASE_URL")
+	if h.token == "" {
+		return fmt.Errorf("gitea: GITEA_TOKEN is not set")
+	}
+	if h.baseURL == "" {
+		return fmt.Errorf("gitea: GITEA_BASE_URL is not set")
+	}
+	h.baseURL = strings.TrimSuffix(h.baseURL, "/")
+	return nil
+}
+
+func (h *giteaHost) Setup(pkg, version, desc string) (Release, error) {
+	owner, repo, err := giteaOwnerRepo(pkg)
+	if err != nil {
+		return nil, err
+	}
+	body, err := json.Marshal(map[string]interface{}{
+		"tag_name": version,
+		"name":     version,
+		"body":     desc,
+	})
+	if err != nil {
+		return nil, err
+	}
+	url := fmt.Sprintf("%s/api/v1/repos/%s/%s/releases", h.baseURL, owner, repo)
+	req, err := http.NewRequest("POST", url, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Authorization", "token "+h.token)
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusCreated {
+		return nil, fmt.Errorf("gitea: failed to create release %s (%s)", version, resp.Status)
+	}
+	var created struct {
+		ID int64 `json:"id"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&created); err != nil {
+		return nil, err
+	}
+	return &giteaRelease{
+		token:   h.token,
+		baseURL: h.baseURL,
+		owner:   owner,
+		repo:    repo,
+		id:      created.ID,
+	}, nil
+}
+
+type giteaRelease struct {
+	token, baseURL, owner, repo string
+	id                          int64
+}
+
+func (r *giteaRelease) Upload(name string, b []byte) error {
+	url := fmt.Sprintf("%s/api/v1/repos/%s/%s/releases/%d/assets?name=%s", r.baseURL, r.owner, r.repo, r.id, name)
+	req, err := http.NewRequest("POST", url, bytes.NewReader(b))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Authorization", "token "+r.token)
+	req.Header.Set("Content-Type", "application/octet-stream")
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusCreated {
+		return fmt.Errorf("gitea: failed to upload asset %s (%s)", name, resp.Status)
+	}
+	return nil
+}
+
+//giteaOwnerRepo infers the "owner/repo" release target from a Go
+//package path hosted on the configured Gitea instance
+func giteaOwnerRepo(pkg string) (owner, repo string, err error) {
+	parts := strings.Split(pkg, "/")
+	if len(parts) < 2 {
+		return "", "", fmt.Errorf("gitea: cannot infer owner/repo from package %q", pkg)
+	}
+	return parts[len(parts)-2], parts[len(parts)-1], nil
+}
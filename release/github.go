@@ -0,0 +1,103 @@
+package release
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+)
+
+//Github releases assets against api.github.com, authenticating with a
+//personal access token supplied via GITHUB_TOKEN (or GH_PAN, which
+//cloud-gox already uses to clone private repositories).
+var Github ReleaseHost = &githubHost{}
+
+type githubHost struct {
+	token string
+}
+
+func (g *githubHost) Auth() error {
+	g.token = os.Getenv("GITHUB_TOKEN")
+	if g.token == "" {
+		g.token = os.Getenv("GH_PAN")
+	}
+	if g.token == "" {
+		return fmt.Errorf("github: GITHUB_TOKEN (or GH_PAN) is not set")
+	}
+	return nil
+}
+
+func (g *githubHost) Setup(pkg, version, desc string) (Release, error) {
+	owner, repo, err := githubOwnerRepo(pkg)
+	if err != nil {
+		return nil, err
+	}
+	body, err := json.Marshal(map[string]interface{}{
+		"tag_name": version,
+		"name":     version,
+		"body":     desc,
+	})
+	if err != nil {
+		return nil, err
+	}
+	url := fmt.Sprintf("https://api.github.com/repos/%s/%s/releases", owner, repo)
+	req, err := http.NewRequest("POST", url, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Authorization", "token "+g.token)
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusCreated {
+		return nil, fmt.Errorf("github: failed to create release %s (%s)", version, resp.Status)
+	}
+	var created struct {
+		UploadURL string `json:"upload_url"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&created); err != nil {
+		return nil, err
+	}
+	return &githubRelease{
+		token:     g.token,
+		uploadURL: strings.SplitN(created.UploadURL, "{", 2)[0],
+	}, nil
+}
+
+type githubRelease struct {
+	token     string
+	uploadURL string
+}
+
+func (r *githubRelease) Upload(name string, b []byte) error {
+	req, err := http.NewRequest("POST", r.uploadURL+"?name="+name, bytes.NewReader(b))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Authorization", "token "+r.token)
+	req.Header.Set("Content-Type", "application/octet-stream")
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusCreated {
+		return fmt.Errorf("github: failed to upload asset %s (%s)", name, resp.Status)
+	}
+	return nil
+}
+
+//githubOwnerRepo infers the "owner/repo" release target from a Go
+//package path, e.g. "github.com/admpub/cloud-gox" -> "admpub", "cloud-gox"
+func githubOwnerRepo(pkg string) (owner, repo string, err error) {
+	parts := strings.Split(strings.TrimPrefix(pkg, "github.com/"), "/")
+	if len(parts) < 2 {
+		return "", "", fmt.Errorf("github: cannot infer owner/repo from package %q", pkg)
+	}
+	return parts[0], parts[1], nil
+}
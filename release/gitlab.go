@@ -0,0 +1,92 @@
+package release
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"os"
+)
+
+//GitLab releases assets via the GitLab Releases API, configured with
+//GITLAB_TOKEN, GITLAB_PROJECT_ID and an optional GITLAB_BASE_URL for
+//self-hosted instances (defaults to gitlab.com).
+var GitLab ReleaseHost = &gitlabHost{}
+
+type gitlabHost struct {
+	token, projectID, baseURL string
+}
+
+func (h *gitlabHost) Auth() error {
+	h.token = os.Getenv("GITLAB_TOKEN")
+	h.projectID = os.Getenv("GITLAB_PROJECT_ID")
+	h.baseURL = os.Getenv("GITLAB_BASE_URL")
+	if h.token == "" {
+		return fmt.Errorf("gitlab: GITLAB_TOKEN is not set")
+	}
+	if h.projectID == "" {
+		return fmt.Errorf("gitlab: GITLAB_PROJECT_ID is not set")
+	}
+	if h.baseURL == "" {
+		h.baseURL = "https://gitlab.com"
+	}
+	return nil
+}
+
+func (h *gitlabHost) Setup(pkg, version, desc string) (Release, error) {
+	body, err := json.Marshal(map[string]interface{}{
+		"tag_name":    version,
+		"name":        version,
+		"description": desc,
+	})
+	if err != nil {
+		return nil, err
+	}
+	url := fmt.Sprintf("%s/api/v4/projects/%s/releases", h.baseURL, h.projectID)
+	req, err := http.NewRequest("POST", url, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("PRIVATE-TOKEN", h.token)
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusCreated {
+		return nil, fmt.Errorf("gitlab: failed to create release %s (%s)", version, resp.Status)
+	}
+	return &gitlabRelease{host: h, version: version}, nil
+}
+
+type gitlabRelease struct {
+	host    *gitlabHost
+	version string
+}
+
+//Upload pushes the asset as a file of the "cloud-gox" generic package,
+//at the release's version, to the project's package registry, since
+//GitLab releases don't accept raw binary attachments the way
+//GitHub/Gitea do. It does not add a link to the asset on the release.
+func (r *gitlabRelease) Upload(name string, b []byte) error {
+	h := r.host
+	pkgURL := fmt.Sprintf("%s/api/v4/projects/%s/packages/generic/cloud-gox/%s/%s", h.baseURL, h.projectID, r.version, name)
+	req, err := http.NewRequest("PUT", pkgURL, bytes.NewReader(b))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("PRIVATE-TOKEN", h.token)
+	req.Header.Set("Content-Type", "application/octet-stream")
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusCreated && resp.StatusCode != http.StatusOK {
+		body, _ := ioutil.ReadAll(resp.Body)
+		return fmt.Errorf("gitlab: failed to upload asset %s (%s): %s", name, resp.Status, body)
+	}
+	return nil
+}
@@ -0,0 +1,24 @@
+//Package release implements the pluggable backends that a finished
+//Compilation's artifacts can be published to.
+package release
+
+//ReleaseHost authenticates against a release backend and, once
+//authenticated, creates the Release that a build's assets should be
+//uploaded to.
+type ReleaseHost interface {
+	//Auth loads the backend's configuration from whatever environment
+	//variables that backend requires, returning an error when a required
+	//one is missing. It makes no request to the backend itself.
+	Auth() error
+	//Setup creates the release for pkg at version, using desc as its
+	//release notes, ready to receive uploaded assets. An existing release
+	//for the same version is not looked up or reused.
+	Setup(pkg, version, desc string) (Release, error)
+}
+
+//Release is a single release on a backend, ready to receive uploaded
+//build artifacts.
+type Release interface {
+	//Upload adds name (containing b) as an asset of this release.
+	Upload(name string, b []byte) error
+}
@@ -0,0 +1,129 @@
+package release
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+)
+
+//S3 releases assets to an S3-compatible object store (AWS S3 or a
+//MinIO-style endpoint), configured via AWS_ACCESS_KEY_ID,
+//AWS_SECRET_ACCESS_KEY, AWS_REGION, S3_BUCKET and the optional
+//S3_ENDPOINT (set to point at a MinIO server instead of AWS).
+var S3 ReleaseHost = &s3Host{}
+
+type s3Host struct {
+	accessKey, secretKey, region, bucket, endpoint string
+}
+
+func (h *s3Host) Auth() error {
+	h.accessKey = os.Getenv("AWS_ACCESS_KEY_ID")
+	h.secretKey = os.Getenv("AWS_SECRET_ACCESS_KEY")
+	h.region = os.Getenv("AWS_REGION")
+	h.bucket = os.Getenv("S3_BUCKET")
+	h.endpoint = os.Getenv("S3_ENDPOINT")
+	if h.accessKey == "" || h.secretKey == "" {
+		return fmt.Errorf("s3: AWS_ACCESS_KEY_ID/AWS_SECRET_ACCESS_KEY are not set")
+	}
+	if h.bucket == "" {
+		return fmt.Errorf("s3: S3_BUCKET is not set")
+	}
+	if h.region == "" {
+		h.region = "us-east-1"
+	}
+	if h.endpoint == "" {
+		h.endpoint = fmt.Sprintf("https://s3.%s.amazonaws.com", h.region)
+	}
+	return nil
+}
+
+func (h *s3Host) Setup(pkg, version, desc string) (Release, error) {
+	//S3 has no concept of a release; assets for this version are simply
+	//namespaced under a "pkg/version/" key prefix within the bucket
+	return &s3Release{host: h, prefix: strings.Trim(pkg, "/") + "/" + version}, nil
+}
+
+type s3Release struct {
+	host   *s3Host
+	prefix string
+}
+
+func (r *s3Release) Upload(name string, b []byte) error {
+	key := r.prefix + "/" + name
+	reqURL := fmt.Sprintf("%s/%s/%s", r.host.endpoint, r.host.bucket, key)
+	req, err := http.NewRequest("PUT", reqURL, bytes.NewReader(b))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/octet-stream")
+	if err := signAWS4(req, b, r.host); err != nil {
+		return err
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("s3: failed to upload %s (%s)", key, resp.Status)
+	}
+	return nil
+}
+
+//signAWS4 applies a minimal AWS Signature Version 4 header to req, good
+//enough for a single-shot PUT against S3 or a MinIO endpoint
+func signAWS4(req *http.Request, body []byte, h *s3Host) error {
+	now := time.Now().UTC()
+	amzDate := now.Format("20060102T150405Z")
+	dateStamp := now.Format("20060102")
+	payloadHash := sha256Hex(body)
+
+	req.Header.Set("X-Amz-Date", amzDate)
+	req.Header.Set("X-Amz-Content-Sha256", payloadHash)
+
+	host := req.URL.Host
+	canonicalHeaders := fmt.Sprintf("host:%s\nx-amz-content-sha256:%s\nx-amz-date:%s\n", host, payloadHash, amzDate)
+	signedHeaders := "host;x-amz-content-sha256;x-amz-date"
+	canonicalRequest := strings.Join([]string{
+		req.Method,
+		req.URL.EscapedPath(),
+		"",
+		canonicalHeaders,
+		signedHeaders,
+		payloadHash,
+	}, "\n")
+
+	scope := fmt.Sprintf("%s/%s/s3/aws4_request", dateStamp, h.region)
+	stringToSign := strings.Join([]string{
+		"AWS4-HMAC-SHA256",
+		amzDate,
+		scope,
+		sha256Hex([]byte(canonicalRequest)),
+	}, "\n")
+
+	signingKey := hmacSHA256(hmacSHA256(hmacSHA256(hmacSHA256([]byte("AWS4"+h.secretKey), dateStamp), h.region), "s3"), "aws4_request")
+	signature := hex.EncodeToString(hmacSHA256(signingKey, stringToSign))
+
+	req.Header.Set("Authorization", fmt.Sprintf(
+		"AWS4-HMAC-SHA256 Credential=%s/%s, SignedHeaders=%s, Signature=%s",
+		h.accessKey, scope, signedHeaders, signature,
+	))
+	return nil
+}
+
+func sha256Hex(b []byte) string {
+	sum := sha256.Sum256(b)
+	return hex.EncodeToString(sum[:])
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(data))
+	return mac.Sum(nil)
+}